@@ -0,0 +1,76 @@
+package goworker
+
+import (
+	"context"
+
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// Backend is the minimal surface worker.go needs from a Redis connection.
+// It is satisfied by RedisConn (backed by redigo) and by GoRedisBackend
+// (backed by go-redis/v8), so the same worker code runs unchanged whether
+// Resque is fronted by a single node, Sentinel, or a Cluster.
+type Backend interface {
+	Send(cmd string, args ...interface{}) error
+	Flush() error
+	Do(cmd string, args ...interface{}) (interface{}, error)
+}
+
+// GoRedisBackend implements Backend on top of github.com/go-redis/redis/v8,
+// pipelining Send calls and executing them on Flush the way RedisConn does
+// for redigo. It is selected by NewBackend when workerSettings carries
+// Sentinel or Cluster topology, or when the caller asks for it explicitly.
+type GoRedisBackend struct {
+	client goredis.UniversalClient
+	pipe   goredis.Pipeliner
+}
+
+// NewGoRedisBackend builds a GoRedisBackend for a single Redis node, a
+// Sentinel-fronted master, or a Cluster, depending on which of addrs,
+// settings.SentinelAddrs, and settings.ClusterAddrs is populated. It uses
+// go-redis's UniversalClient, which picks the right client (single,
+// Failover, or Cluster) from MasterName and the number of Addrs, so all
+// three topologies share one code path.
+func NewGoRedisBackend(settings WorkerSettings, addrs []string) *GoRedisBackend {
+	opts := &goredis.UniversalOptions{MasterName: settings.MasterName}
+	switch {
+	case len(settings.ClusterAddrs) > 0:
+		opts.Addrs = settings.ClusterAddrs
+	case len(settings.SentinelAddrs) > 0:
+		opts.Addrs = settings.SentinelAddrs
+	default:
+		opts.Addrs = addrs
+	}
+	return &GoRedisBackend{client: goredis.NewUniversalClient(opts)}
+}
+
+// Send queues cmd on the backend's pipeline, starting one lazily if Flush
+// has not yet been called since the last one.
+func (b *GoRedisBackend) Send(cmd string, args ...interface{}) error {
+	if b.pipe == nil {
+		b.pipe = b.client.Pipeline()
+	}
+	cmdArgs := make([]interface{}, 0, len(args)+1)
+	cmdArgs = append(cmdArgs, cmd)
+	cmdArgs = append(cmdArgs, args...)
+	b.pipe.Do(context.Background(), cmdArgs...)
+	return nil
+}
+
+// Flush executes every command queued by Send since the last Flush.
+func (b *GoRedisBackend) Flush() error {
+	if b.pipe == nil {
+		return nil
+	}
+	_, err := b.pipe.Exec(context.Background())
+	b.pipe = nil
+	return err
+}
+
+// Do executes cmd immediately, bypassing the pipeline.
+func (b *GoRedisBackend) Do(cmd string, args ...interface{}) (interface{}, error) {
+	cmdArgs := make([]interface{}, 0, len(args)+1)
+	cmdArgs = append(cmdArgs, cmd)
+	cmdArgs = append(cmdArgs, args...)
+	return b.client.Do(context.Background(), cmdArgs...).Result()
+}
@@ -0,0 +1,71 @@
+package goworker
+
+import (
+	"fmt"
+	"sync"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// RedisConn is the default Backend implementation: a single redigo
+// connection. It is what NewBackend returns unless workerSettings
+// configures Sentinel (MasterName/SentinelAddrs) or Cluster (ClusterAddrs)
+// topology, in which case GoRedisBackend is used instead.
+type RedisConn struct {
+	redigo.Conn
+}
+
+func (c *RedisConn) Send(cmd string, args ...interface{}) error {
+	return c.Conn.Send(cmd, args...)
+}
+
+func (c *RedisConn) Flush() error {
+	return c.Conn.Flush()
+}
+
+func (c *RedisConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	return c.Conn.Do(cmd, args...)
+}
+
+// NewBackend builds the Backend goworker should talk to Redis through, for
+// a single addr unless settings carries Sentinel or Cluster topology.
+func NewBackend(settings WorkerSettings, addr string) (Backend, error) {
+	if len(settings.SentinelAddrs) > 0 || len(settings.ClusterAddrs) > 0 {
+		return NewGoRedisBackend(settings, []string{addr}), nil
+	}
+	if addr == "" {
+		return nil, fmt.Errorf("goworker: no redis address configured")
+	}
+	conn, err := redigo.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisConn{Conn: conn}, nil
+}
+
+var connPool = sync.Pool{
+	New: func() interface{} {
+		conn, err := NewBackend(workerSettings, workerSettings.URI)
+		if err != nil {
+			logger.Criticalf("Error creating backend connection: %v", err)
+			return nil
+		}
+		return conn
+	},
+}
+
+// GetConn returns a Backend connection from the pool, built by NewBackend
+// according to workerSettings' topology (redigo by default, go-redis for
+// Sentinel or Cluster).
+func GetConn() (Backend, error) {
+	conn, ok := connPool.Get().(Backend)
+	if !ok || conn == nil {
+		return nil, fmt.Errorf("goworker: unable to get a redis connection")
+	}
+	return conn, nil
+}
+
+// PutConn returns conn to the pool for reuse.
+func PutConn(conn Backend) {
+	connPool.Put(conn)
+}
@@ -0,0 +1,20 @@
+package goworker
+
+import "time"
+
+// failure is the JSON shape Resque's failed list and resque-web expect for
+// a failed job.
+type failure struct {
+	FailedAt  time.Time `json:"failed_at"`
+	Payload   Payload   `json:"payload"`
+	Exception string    `json:"exception"`
+	Error     string    `json:"error"`
+	Backtrace []string  `json:"backtrace"`
+	Worker    *worker   `json:"worker"`
+	Queue     string    `json:"queue"`
+
+	// Attempts is how many times a job registered through
+	// RegisterWithRetry ran before its retries were exhausted and it
+	// landed here. Zero for jobs with no retry policy.
+	Attempts int `json:"retry_count,omitempty"`
+}
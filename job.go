@@ -0,0 +1,14 @@
+package goworker
+
+// Payload is the Resque job payload: the registered worker class and the
+// arguments it was enqueued with.
+type Payload struct {
+	Class string        `json:"class"`
+	Args  []interface{} `json:"args"`
+}
+
+// Job is a unit of work popped off a Resque queue.
+type Job struct {
+	Queue   string
+	Payload Payload
+}
@@ -0,0 +1,35 @@
+package goworker
+
+import "context"
+
+// JobHandler is the unit of work middleware wraps: given a job, run it to
+// completion (recording it with Redis, invoking the registered func, and
+// reporting success or failure) and return any error.
+type JobHandler func(ctx context.Context, job *Job) error
+
+// Middleware wraps a JobHandler with cross-cutting behavior, such as
+// metrics, tracing, or auth, and returns the wrapped handler.
+type Middleware func(next JobHandler) JobHandler
+
+var middlewares []Middleware
+
+// Use registers mw around every job the worker pool runs. Middlewares run
+// in registration order: the first one registered is outermost and the
+// last one registered is innermost. Every middleware wraps the whole
+// start/execute/finish sequence, so each sees the job before w.start
+// records it in Redis and after w.finish reports its outcome. Any
+// middleware can short-circuit by returning an error
+// without calling next, which is handled the same way a failed job is.
+func Use(mw Middleware) {
+	middlewares = append(middlewares, mw)
+}
+
+// buildHandler wraps base, the innermost start/execute/finish sequence, in
+// every registered middleware.
+func buildHandler(base JobHandler) JobHandler {
+	handler := base
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
@@ -0,0 +1,34 @@
+package goworker
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var tracer = otel.Tracer("github.com/Shop2market/goworker")
+
+// OpenTelemetryMiddleware is a built-in Middleware that starts a span named
+// after the job's class around its execution, recording the queue and
+// class as attributes and the error, if any, as the span's status.
+// Register it with Use(goworker.OpenTelemetryMiddleware).
+func OpenTelemetryMiddleware(next JobHandler) JobHandler {
+	return func(ctx context.Context, job *Job) error {
+		ctx, span := tracer.Start(ctx, job.Payload.Class)
+		defer span.End()
+
+		span.SetAttributes(
+			attribute.String("goworker.queue", job.Queue),
+			attribute.String("goworker.class", job.Payload.Class),
+		)
+
+		err := next(ctx, job)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return err
+	}
+}
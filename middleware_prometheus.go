@@ -0,0 +1,71 @@
+package goworker
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	prometheusNamespace = "goworker"
+	prometheusSubsystem = "job"
+)
+
+// NewPrometheusMiddleware builds a Middleware that records processed_total,
+// failed_total, and duration_seconds metrics for every job, labeled by job
+// class, under the goworker_job_ namespace. The metrics are registered
+// into reg rather than forced onto the global default registry, so callers
+// that run their own prometheus.Registry avoid collisions with other
+// collectors and control when (or whether) registration happens.
+func NewPrometheusMiddleware(reg prometheus.Registerer) Middleware {
+	processedTotal := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: prometheusSubsystem,
+			Name:      "processed_total",
+			Help:      "Total number of jobs goworker has finished running, per class.",
+		},
+		[]string{"class"},
+	)
+	failedTotal := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: prometheusSubsystem,
+			Name:      "failed_total",
+			Help:      "Total number of jobs that returned an error, per class.",
+		},
+		[]string{"class"},
+	)
+	durationSeconds := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: prometheusSubsystem,
+			Name:      "duration_seconds",
+			Help:      "Job execution time in seconds, per class.",
+		},
+		[]string{"class"},
+	)
+	reg.MustRegister(processedTotal, failedTotal, durationSeconds)
+
+	return func(next JobHandler) JobHandler {
+		return func(ctx context.Context, job *Job) error {
+			start := time.Now()
+			err := next(ctx, job)
+			durationSeconds.WithLabelValues(job.Payload.Class).Observe(time.Since(start).Seconds())
+			processedTotal.WithLabelValues(job.Payload.Class).Inc()
+			if err != nil {
+				failedTotal.WithLabelValues(job.Payload.Class).Inc()
+			}
+			return err
+		}
+	}
+}
+
+// PrometheusMiddleware builds the same Middleware as
+// NewPrometheusMiddleware, registered against prometheus.DefaultRegisterer
+// for callers happy to use the global registry. Register it with
+// Use(goworker.PrometheusMiddleware()).
+func PrometheusMiddleware() Middleware {
+	return NewPrometheusMiddleware(prometheus.DefaultRegisterer)
+}
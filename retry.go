@@ -0,0 +1,249 @@
+package goworker
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// RetryPolicy configures how a job class registered through
+// RegisterWithRetry is retried after it fails, compatible with
+// resque-retry's semantics.
+type RetryPolicy struct {
+	// MaxAttempts is how many times a job may run before its retries are
+	// exhausted and it is moved to the normal failed list.
+	MaxAttempts int
+
+	// Backoff is how long to wait before each retry, indexed by attempt
+	// number (Backoff[0] is the delay before the first retry). The last
+	// entry is reused for every attempt beyond len(Backoff). Defaults to
+	// DefaultBackoff when left nil.
+	Backoff []time.Duration
+}
+
+// DefaultBackoff mirrors resque-retry's default backoff schedule.
+var DefaultBackoff = []time.Duration{
+	0,
+	60 * time.Second,
+	600 * time.Second,
+	3600 * time.Second,
+	10800 * time.Second,
+	21600 * time.Second,
+}
+
+var retryPolicies = make(map[string]RetryPolicy)
+
+// RegisterWithRetry registers fn under class like Register does, but on
+// failure re-enqueues the job onto a delayed schedule up to
+// policy.MaxAttempts times, following policy.Backoff, before letting it
+// land on the normal failed list.
+func RegisterWithRetry(class string, fn workerFunc, policy RetryPolicy) {
+	if len(policy.Backoff) == 0 {
+		policy.Backoff = DefaultBackoff
+	}
+	workers[class] = fn
+	retryPolicies[class] = policy
+}
+
+func backoffFor(policy RetryPolicy, attempt int) time.Duration {
+	if attempt < len(policy.Backoff) {
+		return policy.Backoff[attempt]
+	}
+	return policy.Backoff[len(policy.Backoff)-1]
+}
+
+// retryKey is the Redis key resque-retry uses to track how many times a
+// given job's args have already been attempted.
+func retryKey(class string, args []interface{}) string {
+	buffer, _ := json.Marshal(args)
+	sum := sha1.Sum(buffer)
+	return fmt.Sprintf("%s%s:%s:count", workerSettings.Namespace, class, hex.EncodeToString(sum[:]))
+}
+
+// delayedJob is what scheduleRetry stores under a delayed:<ts> list key: a
+// job's original Resque payload plus the queue it belongs back on.
+type delayedJob struct {
+	Queue   string          `json:"queue"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// scheduleRetry increments job's attempt counter and, if policy allows
+// more attempts, re-enqueues it on the delayed schedule instead of the
+// failed list. It returns whether a retry was scheduled and the attempt
+// number reached, so callers can record it even when retries are
+// exhausted.
+func (w *worker) scheduleRetry(conn Backend, job *Job, policy RetryPolicy) (scheduled bool, attempt int, err error) {
+	key := retryKey(job.Payload.Class, job.Payload.Args)
+	reply, err := conn.Do("INCR", key)
+	if err != nil {
+		return false, 0, err
+	}
+	attempt = toInt(reply)
+
+	// Bound the counter's lifetime to a bit past the longest backoff so a
+	// job that is never retried again (crash, deregistered class) doesn't
+	// leak the key and poison a later job with identical class+args, while
+	// a still-pending retry doesn't lose its count early.
+	var longest time.Duration
+	for _, d := range policy.Backoff {
+		if d > longest {
+			longest = d
+		}
+	}
+	ttl := longest + time.Hour
+	conn.Send("EXPIRE", key, int(ttl.Seconds()))
+
+	if attempt >= policy.MaxAttempts {
+		// Retries are exhausted: clear the counter so it doesn't leak
+		// forever and doesn't poison a later job enqueued with the same
+		// class and args.
+		conn.Send("DEL", key)
+		return false, attempt, conn.Flush()
+	}
+
+	payloadBuffer, err := json.Marshal(job.Payload)
+	if err != nil {
+		return false, attempt, err
+	}
+	delayedBuffer, err := json.Marshal(delayedJob{Queue: job.Queue, Payload: payloadBuffer})
+	if err != nil {
+		return false, attempt, err
+	}
+
+	runAt := time.Now().Add(backoffFor(policy, attempt-1))
+	conn.Send("RPUSH", fmt.Sprintf("%sdelayed:%d", workerSettings.Namespace, runAt.Unix()), delayedBuffer)
+	conn.Send("ZADD", fmt.Sprintf("%sdelayed_queue_schedule", workerSettings.Namespace), runAt.Unix(), runAt.Unix())
+	if err := conn.Flush(); err != nil {
+		return false, attempt, err
+	}
+
+	logger.Debugf("Scheduled retry %d/%d for %s in %s", attempt, policy.MaxAttempts, job.Payload.Class, backoffFor(policy, attempt-1))
+	return true, attempt, nil
+}
+
+// PollDelayedQueue promotes jobs whose retry delay has elapsed from the
+// delayed schedule back onto their original queues. It blocks, so run it
+// in its own goroutine alongside Work().
+func PollDelayedQueue(interval time.Duration, quit <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+			if err := promoteDueJobs(); err != nil {
+				logger.Criticalf("Error promoting delayed jobs: %v", err)
+			}
+		}
+	}
+}
+
+func promoteDueJobs() error {
+	conn, err := GetConn()
+	if err != nil {
+		return err
+	}
+	defer PutConn(conn)
+
+	scheduleKey := fmt.Sprintf("%sdelayed_queue_schedule", workerSettings.Namespace)
+	reply, err := conn.Do("ZRANGEBYSCORE", scheduleKey, "-inf", time.Now().Unix())
+	if err != nil {
+		return err
+	}
+
+	for _, ts := range toStrings(reply) {
+		listKey := fmt.Sprintf("%sdelayed:%s", workerSettings.Namespace, ts)
+		drained := true
+		for {
+			item, err := conn.Do("LPOP", listKey)
+			if err != nil {
+				// redigo signals an empty list with a nil reply, but
+				// go-redis's Do surfaces the same thing as a redis.Nil
+				// error. Either means the list is drained, not that the
+				// pop failed.
+				if errors.Is(err, goredis.Nil) {
+					break
+				}
+				logger.Criticalf("Error popping %s: %v", listKey, err)
+				drained = false
+				break
+			}
+			if item == nil {
+				break
+			}
+			var delayed delayedJob
+			if err := json.Unmarshal(toBytes(item), &delayed); err != nil {
+				logger.Criticalf("Error unmarshalling delayed job: %v", err)
+				continue
+			}
+			conn.Send("RPUSH", fmt.Sprintf("%squeue:%s", workerSettings.Namespace, delayed.Queue), []byte(delayed.Payload))
+		}
+		if !drained {
+			continue
+		}
+		// A concurrent scheduleRetry may have pushed a fresh job onto this
+		// same ts bucket between our last LPOP and here; only drop the
+		// schedule entry if the list is still empty.
+		length, err := conn.Do("LLEN", listKey)
+		if err != nil {
+			logger.Criticalf("Error checking %s length: %v", listKey, err)
+			continue
+		}
+		if toInt(length) == 0 {
+			conn.Send("ZREM", scheduleKey, ts)
+		}
+	}
+	return conn.Flush()
+}
+
+// toInt and toBytes adapt the interface{} a Backend.Do reply comes back
+// as, since redigo and go-redis surface the same Redis reply as different
+// concrete Go types.
+func toInt(reply interface{}) int {
+	switch v := reply.(type) {
+	case int64:
+		return int(v)
+	case int:
+		return v
+	case []byte:
+		var n int
+		fmt.Sscanf(string(v), "%d", &n)
+		return n
+	case string:
+		var n int
+		fmt.Sscanf(v, "%d", &n)
+		return n
+	default:
+		return 0
+	}
+}
+
+func toBytes(reply interface{}) []byte {
+	switch v := reply.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	default:
+		return nil
+	}
+}
+
+func toStrings(reply interface{}) []string {
+	items, ok := reply.([]interface{})
+	if !ok {
+		return nil
+	}
+	strs := make([]string, 0, len(items))
+	for _, item := range items {
+		strs = append(strs, string(toBytes(item)))
+	}
+	return strs
+}
@@ -0,0 +1,68 @@
+package goworker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffFor(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts: 5,
+		Backoff:     []time.Duration{0, 60 * time.Second, 600 * time.Second},
+	}
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 0, want: 0},
+		{attempt: 1, want: 60 * time.Second},
+		{attempt: 2, want: 600 * time.Second},
+		// attempts beyond the schedule reuse the last entry.
+		{attempt: 3, want: 600 * time.Second},
+		{attempt: 10, want: 600 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := backoffFor(policy, tt.attempt); got != tt.want {
+			t.Errorf("backoffFor(policy, %d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultBackoff(t *testing.T) {
+	want := []time.Duration{
+		0,
+		60 * time.Second,
+		600 * time.Second,
+		3600 * time.Second,
+		10800 * time.Second,
+		21600 * time.Second,
+	}
+	if len(DefaultBackoff) != len(want) {
+		t.Fatalf("len(DefaultBackoff) = %d, want %d", len(DefaultBackoff), len(want))
+	}
+	for i := range want {
+		if DefaultBackoff[i] != want[i] {
+			t.Errorf("DefaultBackoff[%d] = %v, want %v", i, DefaultBackoff[i], want[i])
+		}
+	}
+}
+
+func TestRetryKeyStableAndDistinct(t *testing.T) {
+	a := retryKey("HardJob", []interface{}{1, "x"})
+	b := retryKey("HardJob", []interface{}{1, "x"})
+	if a != b {
+		t.Fatalf("retryKey should be deterministic for identical class+args, got %q and %q", a, b)
+	}
+
+	c := retryKey("HardJob", []interface{}{2, "y"})
+	if a == c {
+		t.Fatalf("retryKey should differ for different args, both got %q", a)
+	}
+
+	d := retryKey("OtherJob", []interface{}{1, "x"})
+	if a == d {
+		t.Fatalf("retryKey should differ for different classes, both got %q", a)
+	}
+}
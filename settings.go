@@ -0,0 +1,23 @@
+package goworker
+
+// WorkerSettings holds the configuration goworker uses to connect to Redis
+// and drive its pool of workers.
+type WorkerSettings struct {
+	Namespace string
+
+	// URI is the single-node Redis address NewBackend dials when neither
+	// Sentinel nor Cluster topology is configured below.
+	URI string
+
+	// MasterName and SentinelAddrs configure the go-redis backend to reach
+	// a Redis master through Sentinel rather than connecting to it
+	// directly.
+	MasterName    string
+	SentinelAddrs []string
+
+	// ClusterAddrs configures the go-redis backend to talk to a Redis
+	// Cluster instead of a single node.
+	ClusterAddrs []string
+}
+
+var workerSettings WorkerSettings
@@ -0,0 +1,124 @@
+package goworker
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StackFrame is a single parsed frame out of a goroutine dump, as produced
+// by runtime/debug.Stack() or runtime.Stack().
+type StackFrame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// StackFormatter turns a raw goroutine dump into the compact backtrace
+// Resque's failed list (and resque-web) expect: a handful of short strings
+// instead of debug.Stack()'s full, repetitive multi-line dump.
+type StackFormatter func(raw []byte) []string
+
+var stackFormatter StackFormatter = collapseStack
+
+// SetStackFormatter overrides the formatter goworker applies to every
+// panic, every TimeoutError, and every WorkerError built by
+// NewWorkerErrorFromStack. fn receives the raw output of
+// runtime/debug.Stack() (or runtime.Stack) and returns the frames to store
+// in Resque's failed list. A backtrace passed to NewWorkerError is stored
+// as given and is not run through fn.
+func SetStackFormatter(fn StackFormatter) {
+	stackFormatter = fn
+}
+
+// internalFramePrefixes are the function-name prefixes collapseStack drops
+// by default, since they're the Go runtime or goworker's own plumbing
+// rather than anything the job author can act on.
+var internalFramePrefixes = []string{
+	"runtime.",
+	"github.com/Shop2market/goworker.",
+}
+
+// parseStack splits a raw goroutine dump into frames. debug.Stack() (and
+// runtime.Stack with all set to false) render each frame as a
+// "function(args)" line followed by a "\tfile:line +0x..." line; parseStack
+// pairs those up and skips the leading "goroutine N [state]:" header.
+func parseStack(raw []byte) []StackFrame {
+	var frames []StackFrame
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	var pendingFunc string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "goroutine "):
+			continue
+		case strings.HasPrefix(line, "\t"):
+			if pendingFunc == "" {
+				continue
+			}
+			file, lineNo := parseFileLine(line)
+			frames = append(frames, StackFrame{Function: pendingFunc, File: file, Line: lineNo})
+			pendingFunc = ""
+		case line == "":
+			pendingFunc = ""
+		default:
+			pendingFunc = stripArgs(line)
+		}
+	}
+	return frames
+}
+
+// stripArgs trims the "(0x..., 0x...)" argument list debug.Stack() appends
+// to each function name, leaving just the function.
+func stripArgs(line string) string {
+	if i := strings.LastIndex(line, "("); i != -1 {
+		return line[:i]
+	}
+	return line
+}
+
+// parseFileLine parses a "\t/path/to/file.go:123 +0x45" frame line.
+func parseFileLine(line string) (string, int) {
+	line = strings.TrimSpace(line)
+	if i := strings.Index(line, " +0x"); i != -1 {
+		line = line[:i]
+	}
+	file, lineStr, ok := strings.Cut(line, ":")
+	if !ok {
+		return line, 0
+	}
+	lineNo, _ := strconv.Atoi(lineStr)
+	return file, lineNo
+}
+
+func isInternalFrame(f StackFrame) bool {
+	for _, prefix := range internalFramePrefixes {
+		if strings.HasPrefix(f.Function, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// collapseStack is the default StackFormatter: it parses raw into frames,
+// drops runtime/goworker internal frames, collapses immediate repeats, and
+// renders what's left as "function (file:line)" strings.
+func collapseStack(raw []byte) []string {
+	frames := parseStack(raw)
+	compact := make([]string, 0, len(frames))
+	var last string
+	for _, f := range frames {
+		if isInternalFrame(f) {
+			continue
+		}
+		rendered := fmt.Sprintf("%s (%s:%d)", f.Function, f.File, f.Line)
+		if rendered == last {
+			continue
+		}
+		compact = append(compact, rendered)
+		last = rendered
+	}
+	return compact
+}
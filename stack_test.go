@@ -0,0 +1,77 @@
+package goworker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseStack(t *testing.T) {
+	raw := []byte("goroutine 7 [running]:\n" +
+		"github.com/Shop2market/goworker.(*worker).run(0xc0001a0000)\n" +
+		"\t/go/src/github.com/Shop2market/goworker/worker.go:150 +0x1a2\n" +
+		"main.doWork(0x1, 0x2)\n" +
+		"\t/home/user/app/main.go:42 +0x55\n")
+
+	frames := parseStack(raw)
+
+	want := []StackFrame{
+		{Function: "github.com/Shop2market/goworker.(*worker).run", File: "/go/src/github.com/Shop2market/goworker/worker.go", Line: 150},
+		{Function: "main.doWork", File: "/home/user/app/main.go", Line: 42},
+	}
+	if !reflect.DeepEqual(frames, want) {
+		t.Fatalf("parseStack() = %#v, want %#v", frames, want)
+	}
+}
+
+func TestCollapseStack(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want []string
+	}{
+		{
+			name: "drops internal frames and keeps job frames",
+			raw: "goroutine 7 [running]:\n" +
+				"runtime.gopanic(0xc0001a0000)\n" +
+				"\t/usr/local/go/src/runtime/panic.go:100 +0x1a2\n" +
+				"github.com/Shop2market/goworker.(*worker).run(0xc0001a0000)\n" +
+				"\t/go/src/github.com/Shop2market/goworker/worker.go:150 +0x1a2\n" +
+				"main.doWork(0x1, 0x2)\n" +
+				"\t/home/user/app/main.go:42 +0x55\n",
+			want: []string{"main.doWork (/home/user/app/main.go:42)"},
+		},
+		{
+			name: "collapses immediately repeated frames",
+			raw: "goroutine 7 [running]:\n" +
+				"main.recurse(0x1)\n" +
+				"\t/home/user/app/main.go:10 +0x10\n" +
+				"main.recurse(0x1)\n" +
+				"\t/home/user/app/main.go:10 +0x10\n" +
+				"main.entry(0x0)\n" +
+				"\t/home/user/app/main.go:5 +0x5\n",
+			want: []string{
+				"main.recurse (/home/user/app/main.go:10)",
+				"main.entry (/home/user/app/main.go:5)",
+			},
+		},
+		{
+			name: "empty dump yields no frames",
+			raw:  "goroutine 1 [running]:\n",
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := collapseStack([]byte(tt.raw))
+			if len(got) != len(tt.want) {
+				t.Fatalf("collapseStack() = %#v, want %#v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("collapseStack() = %#v, want %#v", got, tt.want)
+				}
+			}
+		})
+	}
+}
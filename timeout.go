@@ -0,0 +1,35 @@
+package goworker
+
+import (
+	"context"
+	"time"
+)
+
+// WorkerFuncCtx is the context-aware counterpart of workerFunc. It receives
+// a context.Context that is cancelled once the job's registered timeout
+// elapses, so long-running jobs can observe ctx.Done() and return early.
+type WorkerFuncCtx func(ctx context.Context, queue string, args ...interface{}) error
+
+var (
+	workersCtx    = make(map[string]WorkerFuncCtx)
+	workerTimeout = make(map[string]time.Duration)
+)
+
+// RegisterWithTimeout registers fn under name like Register does, but caps
+// its execution time at timeout. If the job has not returned by then, it is
+// marked as failed with a TimeoutError and the worker picks up its next job
+// rather than blocking on it indefinitely.
+func RegisterWithTimeout(name string, fn WorkerFuncCtx, timeout time.Duration) {
+	workersCtx[name] = fn
+	workerTimeout[name] = timeout
+}
+
+// lookupWorkerFuncCtx returns the WorkerFuncCtx and timeout registered for
+// class, if any.
+func lookupWorkerFuncCtx(class string) (WorkerFuncCtx, time.Duration, bool) {
+	fn, ok := workersCtx[class]
+	if !ok {
+		return nil, 0, false
+	}
+	return fn, workerTimeout[class], true
+}
@@ -1,11 +1,12 @@
 package goworker
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"runtime"
 	"runtime/debug"
-	"strings"
 	"sync"
 	"time"
 )
@@ -28,7 +29,7 @@ func (w *worker) MarshalJSON() ([]byte, error) {
 	return json.Marshal(w.String())
 }
 
-func (w *worker) start(conn *RedisConn, job *Job) error {
+func (w *worker) start(conn Backend, job *Job) error {
 	work := &work{
 		Queue:   job.Queue,
 		RunAt:   time.Now(),
@@ -45,9 +46,23 @@ func (w *worker) start(conn *RedisConn, job *Job) error {
 	return conn.Flush()
 }
 
-func (w *worker) fail(conn *RedisConn, job *Job, err error) error {
+func (w *worker) fail(conn Backend, job *Job, err error) error {
+	attempts := 0
+	if policy, ok := retryPolicies[job.Payload.Class]; ok {
+		scheduled, attempt, retryErr := w.scheduleRetry(conn, job, policy)
+		if retryErr != nil {
+			logger.Criticalf("Error scheduling retry for %v: %v", job, retryErr)
+		} else if scheduled {
+			return nil
+		} else {
+			attempts = attempt
+		}
+	}
+
 	var backtrace []string
 	switch typedError := err.(type) {
+	case *TimeoutError:
+		backtrace = typedError.Backtrace
 	case *WorkerError:
 		backtrace = typedError.Backtrace
 	default:
@@ -61,6 +76,7 @@ func (w *worker) fail(conn *RedisConn, job *Job, err error) error {
 		Backtrace: backtrace,
 		Worker:    w,
 		Queue:     job.Queue,
+		Attempts:  attempts,
 	}
 	buffer, err := json.Marshal(failure)
 	if err != nil {
@@ -71,14 +87,17 @@ func (w *worker) fail(conn *RedisConn, job *Job, err error) error {
 	return w.process.fail(conn)
 }
 
-func (w *worker) succeed(conn *RedisConn, job *Job) error {
+func (w *worker) succeed(conn Backend, job *Job) error {
 	conn.Send("INCR", fmt.Sprintf("%sstat:processed", workerSettings.Namespace))
 	conn.Send("INCR", fmt.Sprintf("%sstat:processed:%s", workerSettings.Namespace, w))
+	if _, ok := retryPolicies[job.Payload.Class]; ok {
+		conn.Send("DEL", retryKey(job.Payload.Class, job.Payload.Args))
+	}
 
 	return nil
 }
 
-func (w *worker) finish(conn *RedisConn, job *Job, err error) error {
+func (w *worker) finish(conn Backend, job *Job, err error) error {
 	if err != nil {
 		w.fail(conn, job, err)
 	} else {
@@ -114,7 +133,11 @@ func (w *worker) work(jobs <-chan *Job, monitor *sync.WaitGroup) {
 			}
 		}()
 		for job := range jobs {
-			if workerFunc, ok := workers[job.Payload.Class]; ok {
+			if workerFuncCtx, timeout, ok := lookupWorkerFuncCtx(job.Payload.Class); ok {
+				w.runCtx(job, workerFuncCtx, timeout)
+
+				logger.Debugf("done: (Job{%s} | %s | %v)", job.Queue, job.Payload.Class, job.Payload.Args)
+			} else if workerFunc, ok := workers[job.Payload.Class]; ok {
 				w.run(job, workerFunc)
 
 				logger.Debugf("done: (Job{%s} | %s | %v)", job.Queue, job.Payload.Class, job.Payload.Args)
@@ -136,34 +159,115 @@ func (w *worker) work(jobs <-chan *Job, monitor *sync.WaitGroup) {
 }
 
 func (w *worker) run(job *Job, workerFunc workerFunc) {
-	var err error
-	defer func() {
-		conn, errCon := GetConn()
-		if errCon != nil {
-			logger.Criticalf("Error on getting connection in worker on finish %v: %v", w, errCon)
-			return
-		} else {
-			w.finish(conn, job, err)
-			PutConn(conn)
-		}
-	}()
-	var stackTrace []string
-	defer func() {
-		if r := recover(); r != nil {
-			stackTrace = strings.Split(string(debug.Stack()), "\n")
-			err = NewWorkerError(fmt.Sprint(r), stackTrace)
-		}
-	}()
+	base := func(ctx context.Context, job *Job) error {
+		return w.execute(job, func() (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = NewWorkerErrorFromStack(fmt.Sprint(r), debug.Stack())
+				}
+			}()
+			return workerFunc(job.Queue, job.Payload.Args...)
+		})
+	}
+	w.dispatch(context.Background(), job, base)
+}
+
+// runCtx is the WorkerFuncCtx counterpart of run. It gives the job a
+// context.Context that is cancelled once timeout elapses, but unlike run it
+// does not block waiting for the job goroutine to return: once the context
+// is cancelled, the job is marked as failed with a TimeoutError and the
+// worker moves on to its next job, leaving the job goroutine to notice the
+// cancellation and unwind on its own.
+func (w *worker) runCtx(job *Job, workerFuncCtx WorkerFuncCtx, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	base := func(ctx context.Context, job *Job) error {
+		return w.execute(job, func() error {
+			done := make(chan error, 1)
+			go func() {
+				defer func() {
+					if r := recover(); r != nil {
+						done <- NewWorkerErrorFromStack(fmt.Sprint(r), debug.Stack())
+					}
+				}()
+				done <- workerFuncCtx(ctx, job.Queue, job.Payload.Args...)
+			}()
+
+			select {
+			case err := <-done:
+				return err
+			case <-ctx.Done():
+				return NewTimeoutError(job.Payload.Class, timeout, capturedStack())
+			}
+		})
+	}
+	w.dispatch(ctx, job, base)
+}
 
+// dispatch runs base through the registered middleware chain and makes sure
+// job is never reported to Redis twice. Middlewares normally report
+// the outcome themselves by letting base run (base calls execute, which
+// calls start/finish), but a middleware that returns an error without
+// calling next short-circuits the chain and base never runs. dispatch
+// detects that case and routes the middleware's error to fail itself, so a
+// short-circuited job still ends up on the failed list instead of vanishing
+// silently. A middleware that returns nil without calling next leaves the
+// job unreported.
+func (w *worker) dispatch(ctx context.Context, job *Job, base JobHandler) {
+	executed := false
+	wrapped := func(ctx context.Context, job *Job) error {
+		executed = true
+		return base(ctx, job)
+	}
+
+	err := buildHandler(wrapped)(ctx, job)
+	if err == nil || executed {
+		return
+	}
+
+	conn, connErr := GetConn()
+	if connErr != nil {
+		logger.Criticalf("Error on getting connection in worker %v: %v", w, connErr)
+		return
+	}
+	w.finish(conn, job, err)
+	PutConn(conn)
+}
+
+// execute is the innermost JobHandler in the middleware chain: it records
+// job as started, runs fn, and reports the outcome to Redis, in that
+// order. Middlewares registered with Use wrap around this whole sequence,
+// so they run before w.start and after w.finish.
+func (w *worker) execute(job *Job, fn func() error) error {
 	conn, err := GetConn()
 	if err != nil {
 		logger.Criticalf("Error on getting connection in worker on start %v: %v", w, err)
-		return
-	} else {
-		w.start(conn, job)
-		PutConn(conn)
+		return err
+	}
+	w.start(conn, job)
+	PutConn(conn)
+
+	err = fn()
+
+	conn, errCon := GetConn()
+	if errCon != nil {
+		logger.Criticalf("Error on getting connection in worker on finish %v: %v", w, errCon)
+		return err
 	}
-	err = workerFunc(job.Queue, job.Payload.Args...)
+	w.finish(conn, job, err)
+	PutConn(conn)
+
+	return err
+}
+
+// capturedStack dumps the stacks of all running goroutines, including the
+// job goroutine that is still unwinding after a timeout, so that the
+// TimeoutError's backtrace points at where the job actually got stuck.
+func capturedStack() []byte {
+	buf := make([]byte, 1<<16)
+	n := runtime.Stack(buf, true)
+	return buf[:n]
 }
 
 type WorkerError struct {
@@ -171,10 +275,41 @@ type WorkerError struct {
 	Backtrace []string
 }
 
+// NewWorkerError builds a WorkerError from an already-formatted backtrace.
+// Callers that only have a raw goroutine dump (as produced by
+// runtime/debug.Stack() or runtime.Stack()) should use
+// NewWorkerErrorFromStack instead, so the package's StackFormatter gets a
+// chance to collapse it first.
 func NewWorkerError(message string, backtrace []string) *WorkerError {
 	return &WorkerError{message: message, Backtrace: backtrace}
 }
 
+// NewWorkerErrorFromStack builds a WorkerError from a raw goroutine dump,
+// running it through the package's StackFormatter so the Backtrace stored
+// in Resque's failed list is the collapsed, resque-web-friendly form
+// rather than the raw dump.
+func NewWorkerErrorFromStack(message string, rawStack []byte) *WorkerError {
+	return &WorkerError{message: message, Backtrace: stackFormatter(rawStack)}
+}
+
 func (workerError *WorkerError) Error() string {
 	return workerError.message
 }
+
+// TimeoutError is returned by runCtx when a job registered through
+// RegisterWithTimeout does not complete before its timeout elapses. It
+// embeds a WorkerError so it still surfaces a Backtrace in the Resque
+// failed list.
+type TimeoutError struct {
+	*WorkerError
+	Class   string
+	Timeout time.Duration
+}
+
+func NewTimeoutError(class string, timeout time.Duration, rawStack []byte) *TimeoutError {
+	return &TimeoutError{
+		WorkerError: NewWorkerErrorFromStack(fmt.Sprintf("job %s did not complete within %s", class, timeout), rawStack),
+		Class:       class,
+		Timeout:     timeout,
+	}
+}